@@ -5,13 +5,41 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sync"
 )
 
 // projectConfig 保存从 setting.json 读取的顶层配置。
 type projectConfig struct {
-	Log   log     `json:"log"`
-	Rules []*Rule `json:"rules"`
+	Log       log       `json:"log"`
+	GeoIP     GeoIP     `json:"geoip"`
+	Commander Commander `json:"commander"`
+	QUIC      QUIC      `json:"quic"`
+	Egress    Egress    `json:"egress"`
+	Rules     []*Rule   `json:"rules"`
+}
+
+// QUIC 配置 quic 监听所需的 TLS 证书，供 Transport 为 "quic" 的规则使用。
+type QUIC struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// Egress 配置出站 TLS 拨号（目标 Transport 为 "tcp+tls" 或 "quic"）的信任策略。
+// CAFile 非空时只信任该 CA；留空则使用系统证书池校验对端证书。
+// InsecureSkipVerify 显式关闭校验，仅建议自签名测试环境使用，默认关闭。
+type Egress struct {
+	CAFile             string `json:"caFile"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// Commander 配置内嵌的 gRPC 控制面，Listen 为空表示不启动。
+// Secret 是调用方必须在 "x-commander-secret" metadata 里回传的共享密钥；
+// 为空时 commander 拒绝启动，避免在没有任何鉴权的情况下暴露一个可远程改配置的控制口。
+type Commander struct {
+	Listen string `json:"listen"`
+	Secret string `json:"secret"`
 }
 
 type log struct {
@@ -21,19 +49,105 @@ type log struct {
 	Date    string `json:"date"`
 }
 
+// GeoIP 配置用于 geo 模式及黑名单中的国家代码匹配，path 指向本地 MaxMind .mmdb 文件。
+type GeoIP struct {
+	Path            string `json:"path"`
+	RefreshInterval uint64 `json:"refreshInterval"` // 秒，检测文件变更并热重载的周期
+}
+
 // Rule 描述一个监听端口以及接入流量的路由策略。
+// Blacklist 的 key 除了裸 IP，也可以是 CIDR（如 "1.2.3.0/24"）或国家代码（如 "CN"）。
 type Rule struct {
 	Name    string `json:"name"`
 	Listen  string `json:"listen"`
 	Mode    string `json:"mode"`
 	Prewarm bool   `json:"prewarm"`
-	Targets []*struct {
+	// Transport 是监听端的传输协议："tcp"（默认）或 "quic"。
+	Transport string `json:"transport"`
+	// ProxyProtocol 控制监听端是否在分发前解析 PROXY protocol 头部获取真实客户端地址：
+	// "none"（默认，不解析）、"accept-v1"、"accept-v2"、"accept-any"（自动探测 v1/v2）。
+	ProxyProtocol string `json:"proxyProtocol"`
+	Targets       []*struct {
 		Regexp  string         `json:"regexp"`
 		Re      *regexp.Regexp `json:"-"`
 		Address string         `json:"address"`
+		// Country / ASN 仅用于 geo 模式下的匹配；留空表示不限制，可作为兜底目标。
+		Country []string `json:"country"`
+		ASN     []uint32 `json:"asn"`
+		// Transport 是该目标的拨号方式："tcp"（默认）、"quic" 或 "tcp+tls"。
+		Transport string `json:"transport"`
+		// SendProxy 为 true 时，拨号成功后先发送一个 PROXY protocol v2 头部，
+		// 携带真实客户端地址与原始目的地址，再转发业务数据。
+		SendProxy bool `json:"sendProxy"`
 	} `json:"targets"`
-	Timeout   uint64          `json:"timeout"`
-	Blacklist map[string]bool `json:"blacklist"`
+	Timeout     uint64          `json:"timeout"`
+	Blacklist   map[string]bool `json:"blacklist"`
+	HealthCheck HealthCheck     `json:"healthCheck"`
+	// HashWeight 可选，与 Targets 顺序一一对应，用于 hash 模式下的 HRW 加权；留空或为 0 按权重 1 处理。
+	HashWeight []uint32 `json:"hashWeight"`
+
+	// blacklistMu 保护 Blacklist：commander 的 BlacklistAdd/BlacklistRemove 与 dispatch 热路径上的
+	// matchBlacklist/needsGeoIP 并发读写同一个 map，不加锁会被 Go runtime 检测为并发写并直接 fatal 退出。
+	// 所有对 Blacklist 的访问都必须经由下面的 BlacklistXxx 方法，不要直接读写该字段。
+	blacklistMu sync.RWMutex
+}
+
+// BlacklistHas 并发安全地判断 key 是否精确命中黑名单（不含 CIDR/国家代码匹配，调用方自行处理）。
+func (c *Rule) BlacklistHas(key string) bool {
+	c.blacklistMu.RLock()
+	defer c.blacklistMu.RUnlock()
+	return c.Blacklist[key]
+}
+
+// BlacklistLen 并发安全地返回当前黑名单条目数。
+func (c *Rule) BlacklistLen() int {
+	c.blacklistMu.RLock()
+	defer c.blacklistMu.RUnlock()
+	return len(c.Blacklist)
+}
+
+// BlacklistKeys 返回黑名单当前所有 key 的一份快照，供需要遍历判断 CIDR/国家代码的调用方使用，
+// 避免在持锁状态下调用 geoip.Lookup 等可能耗时的逻辑。
+func (c *Rule) BlacklistKeys() []string {
+	c.blacklistMu.RLock()
+	defer c.blacklistMu.RUnlock()
+	keys := make([]string, 0, len(c.Blacklist))
+	for k := range c.Blacklist {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// BlacklistAdd 并发安全地添加一条黑名单条目，供 commander 的 BlacklistAdd 使用。
+func (c *Rule) BlacklistAdd(key string) {
+	c.blacklistMu.Lock()
+	defer c.blacklistMu.Unlock()
+	if c.Blacklist == nil {
+		c.Blacklist = map[string]bool{}
+	}
+	c.Blacklist[key] = true
+}
+
+// BlacklistRemove 并发安全地移除一条黑名单条目，供 commander 的 BlacklistRemove 使用。
+func (c *Rule) BlacklistRemove(key string) {
+	c.blacklistMu.Lock()
+	defer c.blacklistMu.Unlock()
+	delete(c.Blacklist, key)
+}
+
+// BlacklistReplace 并发安全地整体替换黑名单 map，供 reload 时把新配置原地应用到仍在运行的规则上使用。
+func (c *Rule) BlacklistReplace(entries map[string]bool) {
+	c.blacklistMu.Lock()
+	defer c.blacklistMu.Unlock()
+	c.Blacklist = entries
+}
+
+// HealthCheck 配置 urltest / fallback 模式下的后台健康探测。
+type HealthCheck struct {
+	Interval      uint64 `json:"interval"`      // 秒，默认 30
+	Probe         string `json:"probe"`         // 可选：建连后发送的探测数据，留空则仅做 TCP 拨号探测
+	ProbeTimeout  uint64 `json:"probeTimeout"`  // 毫秒，默认 1000，覆盖拨号+探测+读取的总耗时
+	FailThreshold int    `json:"failThreshold"` // 连续失败次数阈值，默认 3
 }
 
 // （单边模式）已移除加速端和丢包自适应的旧配置。
@@ -41,12 +155,16 @@ type Rule struct {
 // GlobalCfg 指向全局生效的配置对象。
 var GlobalCfg *projectConfig
 
+// loadedPath 记录当前生效配置的来源文件，commander 持久化变更时写回此路径。
+var loadedPath string
+
 func init() {
 	// 支持通过环境变量覆盖配置文件路径
 	path := os.Getenv("MOTO_CONFIG")
 	if path == "" {
 		path = "config/setting.json"
 	}
+	loadedPath = path
 	buf, err := ioutil.ReadFile(path)
 	if err != nil {
 		fmt.Printf("failed to load setting.json: %s\n", err.Error())
@@ -86,6 +204,74 @@ func Reload(path string) error {
 		}
 	}
 	GlobalCfg = cfg
+	loadedPath = path
+	return nil
+}
+
+// ParseRulesFile 读取并严格校验指定路径下的规则集合，但不触碰 GlobalCfg。
+// 供 commander 的 Reload 在应用前解析出候选规则，交给 controller.ReconcileRules
+// 去对比新旧规则、原地更新仍在运行的活对象，而不是像 Reload 一样整体替换 GlobalCfg——
+// 那样会让正在运行的 Listen goroutine 持有的 *Rule 变成没人再读的孤儿对象。
+func ParseRulesFile(path string) ([]*Rule, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg *projectConfig
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return nil, err
+	}
+	for i, v := range cfg.Rules {
+		if err := v.verify(); err != nil {
+			return nil, fmt.Errorf("verify rule failed at pos %d : %s", i, err.Error())
+		}
+	}
+	return cfg.Rules, nil
+}
+
+// ReplaceRules 用 reconciled 规则集合（通常是 controller.ReconcileRules 的返回值）替换当前生效的规则，
+// 并记录其来源路径，供后续 Save() 持久化。
+func ReplaceRules(path string, rules []*Rule) {
+	if GlobalCfg == nil {
+		GlobalCfg = &projectConfig{}
+	}
+	GlobalCfg.Rules = rules
+	loadedPath = path
+}
+
+// LoadedConfigDir 返回当前生效配置文件所在目录，供 commander 校验运行时 Reload 的路径
+// 是否越界到配置目录之外（避免远程调用方拿 Reload 当任意文件读取器用）。
+func LoadedConfigDir() string {
+	return filepath.Dir(loadedPath)
+}
+
+// Save 将当前 GlobalCfg 写回最近一次加载（或 Reload）的配置文件，供 commander 持久化运行时变更。
+func Save() error {
+	if loadedPath == "" {
+		return fmt.Errorf("no config path loaded")
+	}
+	// 序列化期间持有每条规则的 blacklistMu 读锁，避免与 BlacklistAdd/BlacklistRemove 并发读写 Blacklist。
+	snapshot := *GlobalCfg
+	for _, r := range snapshot.Rules {
+		r.blacklistMu.RLock()
+	}
+	buf, err := json.MarshalIndent(&snapshot, "", "  ")
+	for _, r := range snapshot.Rules {
+		r.blacklistMu.RUnlock()
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(loadedPath, buf, 0644)
+}
+
+// FindRule 按名称查找规则，供 commander 等运行时控制接口使用。
+func FindRule(name string) *Rule {
+	for _, v := range GlobalCfg.Rules {
+		if v.Name == name {
+			return v
+		}
+	}
 	return nil
 }
 
@@ -100,15 +286,53 @@ func (c *Rule) verify() error {
 	if len(c.Targets) == 0 {
 		return fmt.Errorf("invalid targets")
 	}
+	if c.Transport == "" {
+		c.Transport = "tcp"
+	}
+	switch c.Transport {
+	case "tcp", "quic":
+	default:
+		return fmt.Errorf("invalid transport %q", c.Transport)
+	}
+	if c.ProxyProtocol == "" {
+		c.ProxyProtocol = "none"
+	}
+	switch c.ProxyProtocol {
+	case "none", "accept-v1", "accept-v2", "accept-any":
+	default:
+		return fmt.Errorf("invalid proxyProtocol %q", c.ProxyProtocol)
+	}
 	if c.Mode == "regex" {
 		if c.Timeout == 0 {
 			c.Timeout = 500
 		}
 	}
+	if c.Mode == "urltest" || c.Mode == "fallback" {
+		if c.HealthCheck.Interval == 0 {
+			c.HealthCheck.Interval = 30
+		}
+		if c.HealthCheck.ProbeTimeout == 0 {
+			c.HealthCheck.ProbeTimeout = 1000
+		}
+		if c.HealthCheck.FailThreshold == 0 {
+			c.HealthCheck.FailThreshold = 3
+		}
+	}
+	if c.Mode == "hash" && len(c.HashWeight) != 0 && len(c.HashWeight) != len(c.Targets) {
+		return fmt.Errorf("hashWeight length %d does not match targets length %d", len(c.HashWeight), len(c.Targets))
+	}
 	for i, v := range c.Targets {
 		if v.Address == "" {
 			return fmt.Errorf("invalid address at pos %d", i)
 		}
+		if v.Transport == "" {
+			v.Transport = "tcp"
+		}
+		switch v.Transport {
+		case "tcp", "quic", "tcp+tls":
+		default:
+			return fmt.Errorf("invalid target transport %q at pos %d", v.Transport, i)
+		}
 		if c.Mode == "regex" {
 			r, err := regexp.Compile(v.Regexp)
 			if err != nil {
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"context"
-	"io"
 	"moto/config"
 	"moto/utils"
 	"net"
@@ -57,7 +56,12 @@ func storeBoostWinner(ruleName, addr string) {
 	boostWinnerCache.Store(ruleName, boostWinnerEntry{addr: addr, expires: time.Now().Add(boostWinnerTTL)})
 }
 
-// 不再单独提供显式 drop 接口，超时或拨号失败自动失效。
+// EvictBoostWinner 供 commander 调用，强制淘汰某条规则当前缓存的胜出线路，下次请求重新竞速。
+func EvictBoostWinner(ruleName string) bool {
+	_, ok := boostWinnerCache.Load(ruleName)
+	boostWinnerCache.Delete(ruleName)
+	return ok
+}
 
 // lazyRevalidate 在后台重新跑一次竞速，不打断现有请求；若发现更快线路则更新缓存。
 func lazyRevalidate(rule *config.Rule) {
@@ -73,7 +77,7 @@ func lazyRevalidate(rule *config.Rule) {
 	for _, v := range rule.Targets {
 		addr := v.Address
 		go func(a string) {
-			if c, err := outboundDial(a); err == nil {
+			if c, err := outboundDial(a, false, nil, nil); err == nil {
 				select {
 				case switchBetter <- dialResult{conn: c, addr: a}:
 				case <-ctx.Done():
@@ -105,6 +109,10 @@ func lazyRevalidate(rule *config.Rule) {
 func HandleBoost(conn net.Conn, rule *config.Rule) {
 	defer conn.Close()
 
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
 	decisionBegin := time.Now()
 
 	if addr, ok, exp := loadBoostWinner(rule.Name); ok {
@@ -116,13 +124,14 @@ func HandleBoost(conn net.Conn, rule *config.Rule) {
 				triggerLazy = true
 			}
 		}
-		if cachedConn, err := outboundDial(addr); err == nil {
+		if cachedConn, err := outboundDial(addr, sendProxyFor(rule, addr), conn.RemoteAddr(), conn.LocalAddr()); err == nil {
 			if tc, ok := cachedConn.(*net.TCPConn); ok {
 				_ = tc.SetNoDelay(true)
 				_ = tc.SetKeepAlive(true)
 				_ = tc.SetKeepAlivePeriod(30 * time.Second)
 			}
 			storeBoostWinner(rule.Name, addr)
+			stat.boostHit.Add(1)
 			fields := []zap.Field{
 				zap.String("ruleName", rule.Name),
 				zap.String("remoteAddr", conn.RemoteAddr().String()),
@@ -142,11 +151,11 @@ func HandleBoost(conn net.Conn, rule *config.Rule) {
 			defer cachedConn.Close()
 
 			go func() {
-				io.Copy(conn, cachedConn)
+				accountedCopy(rule.Name, conn, cachedConn, false)
 				conn.Close()
 				cachedConn.Close()
 			}()
-			io.Copy(cachedConn, conn)
+			accountedCopy(rule.Name, cachedConn, conn, true)
 			return
 		}
 		// 缓存线路拨号失败：直接从缓存移除，下次重新竞速
@@ -159,7 +168,7 @@ func HandleBoost(conn net.Conn, rule *config.Rule) {
 	switchBetter := make(chan dialResult, 1)
 	for _, v := range rule.Targets {
 		go func(address string) {
-			if tryGetQuickConn, err := outboundDial(address); err == nil {
+			if tryGetQuickConn, err := outboundDial(address, sendProxyFor(rule, address), conn.RemoteAddr(), conn.LocalAddr()); err == nil {
 				select {
 				case switchBetter <- dialResult{conn: tryGetQuickConn, addr: address}:
 				case <-ctx.Done():
@@ -188,6 +197,7 @@ func HandleBoost(conn net.Conn, rule *config.Rule) {
 		_ = tc.SetKeepAlivePeriod(30 * time.Second)
 	}
 	storeBoostWinner(rule.Name, winner.addr)
+	stat.boostMiss.Add(1)
 
 	utils.Logger.Debug("建立连接",
 		zap.String("ruleName", rule.Name),
@@ -199,9 +209,9 @@ func HandleBoost(conn net.Conn, rule *config.Rule) {
 	defer winner.conn.Close()
 
 	go func() {
-		io.Copy(conn, winner.conn)
+		accountedCopy(rule.Name, conn, winner.conn, false)
 		conn.Close()
 		winner.conn.Close()
 	}()
-	io.Copy(winner.conn, conn)
+	accountedCopy(rule.Name, winner.conn, conn, true)
 }
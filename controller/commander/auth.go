@@ -0,0 +1,51 @@
+package commander
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// secretMetadataKey 是调用方必须在 gRPC metadata 里回传的共享密钥字段名。
+const secretMetadataKey = "x-commander-secret"
+
+// authorized 校验 ctx 携带的 metadata 中的共享密钥是否与配置的 secret 一致。
+// 用 subtle.ConstantTimeCompare 而不是 ==，避免给出可用于字节级猜测密钥的计时信息。
+func authorized(ctx context.Context, secret string) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	vals := md.Get(secretMetadataKey)
+	if len(vals) != 1 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(vals[0]), []byte(secret)) == 1
+}
+
+// unauthenticated 统一返回给鉴权失败请求的错误，不泄露具体原因。
+var unauthenticated = status.Error(codes.Unauthenticated, "invalid or missing commander secret")
+
+// authUnaryInterceptor 拒绝所有没有携带正确共享密钥的一元调用（Reload、BlacklistAdd 等）。
+func authUnaryInterceptor(secret string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !authorized(ctx, secret) {
+			return nil, unauthenticated
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authStreamInterceptor 拒绝所有没有携带正确共享密钥的流式调用（StreamStats）。
+func authStreamInterceptor(secret string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !authorized(ss.Context(), secret) {
+			return unauthenticated
+		}
+		return handler(srv, ss)
+	}
+}
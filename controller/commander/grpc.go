@@ -0,0 +1,191 @@
+package commander
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodec 让 commander 的 gRPC 服务在没有 protoc 工具链的情况下也能用普通
+// 结构体收发消息：用 JSON 代替 protobuf wire format，其余仍是标准 gRPC（HTTP/2、
+// 超时、流式）语义。codec 名称通过 grpc.CallContentSubtype 在客户端协商。
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return codecName }
+
+const codecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// CommanderServer 是 Commander 服务端需要实现的接口，对应 commander.proto 里声明的方法。
+type CommanderServer interface {
+	Reload(context.Context, *ReloadRequest) (*ReloadResponse, error)
+	BlacklistAdd(context.Context, *BlacklistRequest) (*BlacklistResponse, error)
+	BlacklistRemove(context.Context, *BlacklistRequest) (*BlacklistResponse, error)
+	DrainRule(context.Context, *RuleRequest) (*RuleResponse, error)
+	EvictBoostWinner(context.Context, *RuleRequest) (*RuleResponse, error)
+	ResizePrewarm(context.Context, *ResizePrewarmRequest) (*RuleResponse, error)
+	HealthSnapshot(context.Context, *HealthSnapshotRequest) (*HealthSnapshotResponse, error)
+	StreamStats(*StreamStatsRequest, Commander_StreamStatsServer) error
+}
+
+// Commander_StreamStatsServer 对应 StreamStats 的服务端流。
+type Commander_StreamStatsServer interface {
+	Send(*StatsUpdate) error
+	grpc.ServerStream
+}
+
+type commanderStreamStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *commanderStreamStatsServer) Send(m *StatsUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Commander_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/Reload"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).Reload(ctx, req.(*ReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_BlacklistAdd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlacklistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).BlacklistAdd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/BlacklistAdd"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).BlacklistAdd(ctx, req.(*BlacklistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_BlacklistRemove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlacklistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).BlacklistRemove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/BlacklistRemove"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).BlacklistRemove(ctx, req.(*BlacklistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_DrainRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).DrainRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/DrainRule"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).DrainRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_EvictBoostWinner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).EvictBoostWinner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/EvictBoostWinner"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).EvictBoostWinner(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_ResizePrewarm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResizePrewarmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).ResizePrewarm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/ResizePrewarm"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).ResizePrewarm(ctx, req.(*ResizePrewarmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_HealthSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommanderServer).HealthSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/commander.Commander/HealthSnapshot"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommanderServer).HealthSnapshot(ctx, req.(*HealthSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Commander_StreamStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamStatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CommanderServer).StreamStats(m, &commanderStreamStatsServer{stream})
+}
+
+// ServiceDesc 是 Commander 服务的 gRPC 描述符，RegisterCommanderServer 用它完成注册。
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "commander.Commander",
+	HandlerType: (*CommanderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Reload", Handler: _Commander_Reload_Handler},
+		{MethodName: "BlacklistAdd", Handler: _Commander_BlacklistAdd_Handler},
+		{MethodName: "BlacklistRemove", Handler: _Commander_BlacklistRemove_Handler},
+		{MethodName: "DrainRule", Handler: _Commander_DrainRule_Handler},
+		{MethodName: "EvictBoostWinner", Handler: _Commander_EvictBoostWinner_Handler},
+		{MethodName: "ResizePrewarm", Handler: _Commander_ResizePrewarm_Handler},
+		{MethodName: "HealthSnapshot", Handler: _Commander_HealthSnapshot_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamStats",
+			Handler:       _Commander_StreamStats_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "commander.proto",
+}
+
+// RegisterCommanderServer 把实现注册到一个 *grpc.Server 上。
+func RegisterCommanderServer(s *grpc.Server, srv CommanderServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
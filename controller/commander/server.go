@@ -0,0 +1,176 @@
+package commander
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"moto/config"
+	"moto/controller"
+	"moto/controller/health"
+	"moto/utils"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+const defaultStreamInterval = time.Second
+
+// commanderServer 是 CommanderServer 的默认实现，直接操作全局配置和 controller 包暴露的控制接口。
+// wg 透传给 controller.ReconcileRules，让 Reload 发现的新规则能像启动时一样挂到同一个 WaitGroup 上。
+type commanderServer struct {
+	wg *sync.WaitGroup
+}
+
+// Serve 启动 commander 的 gRPC 监听，Listen 为空时直接返回（不启用控制面）。
+// 未配置 Secret 时拒绝启动：一个可以远程改配置/读任意本地文件的控制口不能没有鉴权。
+func Serve(cfg config.Commander, wg *sync.WaitGroup) {
+	if cfg.Listen == "" {
+		return
+	}
+	if cfg.Secret == "" {
+		utils.Logger.Error("commander.secret 未配置，拒绝启动控制面（避免无鉴权暴露）", zap.String("listen", cfg.Listen))
+		return
+	}
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		utils.Logger.Error("commander 监听失败", zap.String("listen", cfg.Listen), zap.Error(err))
+		return
+	}
+	s := grpc.NewServer(
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.UnaryInterceptor(authUnaryInterceptor(cfg.Secret)),
+		grpc.StreamInterceptor(authStreamInterceptor(cfg.Secret)),
+	)
+	RegisterCommanderServer(s, &commanderServer{wg: wg})
+	utils.Logger.Info("commander 控制面已启动", zap.String("listen", cfg.Listen))
+	if err := s.Serve(listener); err != nil {
+		utils.Logger.Error("commander 服务退出", zap.Error(err))
+	}
+}
+
+// isReloadPathAllowed 要求 Reload 的目标文件必须位于当前生效配置所在目录之内，
+// 防止远程调用方借 Reload 把任意本地文件当 JSON 解析、探测文件系统内容。
+func isReloadPathAllowed(path string) bool {
+	dir, err := filepath.Abs(config.LoadedConfigDir())
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (c *commanderServer) Reload(ctx context.Context, req *ReloadRequest) (*ReloadResponse, error) {
+	if !isReloadPathAllowed(req.Path) {
+		return &ReloadResponse{Ok: false, Error: "path outside config directory: " + config.LoadedConfigDir()}, nil
+	}
+	newRules, err := config.ParseRulesFile(req.Path)
+	if err != nil {
+		return &ReloadResponse{Ok: false, Error: err.Error()}, nil
+	}
+	reconciled := controller.ReconcileRules(newRules, c.wg)
+	config.ReplaceRules(req.Path, reconciled)
+	return &ReloadResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) BlacklistAdd(ctx context.Context, req *BlacklistRequest) (*BlacklistResponse, error) {
+	rule := config.FindRule(req.RuleName)
+	if rule == nil {
+		return &BlacklistResponse{Ok: false, Error: "rule not found: " + req.RuleName}, nil
+	}
+	rule.BlacklistAdd(req.Entry)
+	if err := config.Save(); err != nil {
+		return &BlacklistResponse{Ok: false, Error: err.Error()}, nil
+	}
+	return &BlacklistResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) BlacklistRemove(ctx context.Context, req *BlacklistRequest) (*BlacklistResponse, error) {
+	rule := config.FindRule(req.RuleName)
+	if rule == nil {
+		return &BlacklistResponse{Ok: false, Error: "rule not found: " + req.RuleName}, nil
+	}
+	rule.BlacklistRemove(req.Entry)
+	if err := config.Save(); err != nil {
+		return &BlacklistResponse{Ok: false, Error: err.Error()}, nil
+	}
+	return &BlacklistResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) DrainRule(ctx context.Context, req *RuleRequest) (*RuleResponse, error) {
+	if !controller.DrainRule(req.RuleName) {
+		return &RuleResponse{Ok: false, Error: "rule not listening: " + req.RuleName}, nil
+	}
+	return &RuleResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) EvictBoostWinner(ctx context.Context, req *RuleRequest) (*RuleResponse, error) {
+	controller.EvictBoostWinner(req.RuleName)
+	return &RuleResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) ResizePrewarm(ctx context.Context, req *ResizePrewarmRequest) (*RuleResponse, error) {
+	if !controller.ResizePrewarmPool(req.Address, int(req.Desired)) {
+		return &RuleResponse{Ok: false, Error: "no prewarm pool for address: " + req.Address}, nil
+	}
+	return &RuleResponse{Ok: true}, nil
+}
+
+func (c *commanderServer) HealthSnapshot(ctx context.Context, req *HealthSnapshotRequest) (*HealthSnapshotResponse, error) {
+	checker := health.Get(req.RuleName)
+	if checker == nil {
+		return &HealthSnapshotResponse{}, nil
+	}
+	snap := checker.Snapshot()
+	targets := make([]TargetHealth, len(snap))
+	for i, s := range snap {
+		targets[i] = TargetHealth{
+			Address:     s.Address,
+			RTTMs:       s.RTT.Milliseconds(),
+			ConsecFails: int32(s.ConsecFails),
+			Healthy:     s.Healthy,
+		}
+	}
+	return &HealthSnapshotResponse{Targets: targets}, nil
+}
+
+func (c *commanderServer) StreamStats(req *StreamStatsRequest, stream Commander_StreamStatsServer) error {
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		snap := controller.StatsSnapshot(req.RuleName)
+		update := &StatsUpdate{
+			RuleName:        req.RuleName,
+			Accepted:        snap.Accepted,
+			Active:          snap.Active,
+			BytesIn:         snap.BytesIn,
+			BytesOut:        snap.BytesOut,
+			WAFDropped:      snap.WAFDropped,
+			BoostHit:        snap.BoostHit,
+			BoostMiss:       snap.BoostMiss,
+			RoundRobinIndex: snap.RoundRobinIdx,
+		}
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package commander
+
+// 以下类型对应 commander.proto 中的消息。由于项目未引入 protoc 工具链，
+// 这些类型并非 protoc-gen-go 的产物，而是配合 jsonCodec 直接参与 gRPC 编解码的普通结构体。
+
+type ReloadRequest struct {
+	Path string `json:"path"`
+}
+
+type ReloadResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BlacklistRequest struct {
+	RuleName string `json:"ruleName"`
+	Entry    string `json:"entry"`
+}
+
+type BlacklistResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type RuleRequest struct {
+	RuleName string `json:"ruleName"`
+}
+
+type RuleResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type ResizePrewarmRequest struct {
+	Address string `json:"address"`
+	Desired int32  `json:"desired"`
+}
+
+type StreamStatsRequest struct {
+	RuleName   string `json:"ruleName"`
+	IntervalMs int64  `json:"intervalMs"`
+}
+
+type HealthSnapshotRequest struct {
+	RuleName string `json:"ruleName"`
+}
+
+type TargetHealth struct {
+	Address     string `json:"address"`
+	RTTMs       int64  `json:"rttMs"`
+	ConsecFails int32  `json:"consecFails"`
+	Healthy     bool   `json:"healthy"`
+}
+
+type HealthSnapshotResponse struct {
+	Targets []TargetHealth `json:"targets"`
+}
+
+type StatsUpdate struct {
+	RuleName        string `json:"ruleName"`
+	Accepted        int64  `json:"accepted"`
+	Active          int64  `json:"active"`
+	BytesIn         int64  `json:"bytesIn"`
+	BytesOut        int64  `json:"bytesOut"`
+	WAFDropped      int64  `json:"wafDropped"`
+	BoostHit        int64  `json:"boostHit"`
+	BoostMiss       int64  `json:"boostMiss"`
+	RoundRobinIndex int64  `json:"roundRobinIndex"`
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/netip"
 	"time"
@@ -84,3 +85,27 @@ func DialFast(addr string) (net.Conn, error) {
 		return &dialConn{Conn: c, latency: time.Since(start)}, nil
 	}
 }
+
+// DialFastTLS 先用 DialFast 建立明文 TCP 连接，再在其上完成 TLS 握手，
+// 供 Transport 为 "tcp+tls" 的目标使用。
+func DialFastTLS(addr string) (net.Conn, error) {
+	raw, err := DialFast(addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	tlsConf, err := egressTLSConfig(host)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(raw, tlsConf)
+	if err := tlsConn.HandshakeContext(context.Background()); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
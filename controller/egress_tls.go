@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"moto/config"
+)
+
+// egressTLSConfig 构造出站 TLS 拨号（tcp+tls 目标、quic 目标）共用的信任配置。
+// 默认校验对端证书链；Egress.CAFile 非空时只信任该 CA；Egress.InsecureSkipVerify
+// 用于显式关闭校验，仅建议在自签名测试环境使用，不应作为默认值。
+func egressTLSConfig(serverName string) (*tls.Config, error) {
+	cfg := config.GlobalCfg.Egress
+	tlsConf := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read egress CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid CA certificate in %s", cfg.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	return tlsConf, nil
+}
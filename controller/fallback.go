@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"moto/config"
+	"moto/controller/health"
+	"moto/utils"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HandleFallback 按声明顺序挨个尝试健康目标，只有拨号失败时才继续下一个；
+// 全员不健康或拨号均失败时退化到 HandleBoost。
+func HandleFallback(conn net.Conn, rule *config.Rule) {
+	defer conn.Close()
+
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
+	checker := health.Start(rule)
+
+	var target net.Conn
+	for _, s := range checker.Snapshot() {
+		if !s.Healthy {
+			continue
+		}
+		c, err := outboundDial(s.Address, sendProxyFor(rule, s.Address), conn.RemoteAddr(), conn.LocalAddr())
+		if err != nil {
+			utils.Logger.Error("fallback: 无法建立连接，尝试下一个目标",
+				zap.String("ruleName", rule.Name),
+				zap.String("targetAddr", s.Address))
+			continue
+		}
+		target = c
+		break
+	}
+	if target == nil {
+		utils.Logger.Warn("fallback: 没有可用目标，退化到 boost 模式",
+			zap.String("ruleName", rule.Name))
+		HandleBoost(conn, rule)
+		return
+	}
+	if tc, ok := target.(*net.TCPConn); ok {
+		_ = tc.SetNoDelay(true)
+		_ = tc.SetKeepAlive(true)
+		_ = tc.SetKeepAlivePeriod(30 * time.Second)
+	}
+	utils.Logger.Debug("建立连接",
+		zap.String("ruleName", rule.Name),
+		zap.String("remoteAddr", conn.RemoteAddr().String()),
+		zap.String("targetAddr", target.RemoteAddr().String()))
+
+	defer target.Close()
+
+	go func() {
+		accountedCopy(rule.Name, conn, target, false)
+		conn.Close()
+		target.Close()
+	}()
+	accountedCopy(rule.Name, target, conn, true)
+}
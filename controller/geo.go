@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"moto/config"
+	"moto/utils"
+	"moto/utils/geoip"
+
+	"go.uber.org/zap"
+)
+
+var geoInitOnce sync.Once
+
+// initGeoIP 按 projectConfig 中的 geoip 配置加载 mmdb 并启动热重载，整个进程只需初始化一次。
+func initGeoIP() {
+	geoInitOnce.Do(func() {
+		gc := config.GlobalCfg.GeoIP
+		if gc.Path == "" {
+			utils.Logger.Warn("geo 模式或黑名单国家匹配已启用，但未配置 geoip.path")
+			return
+		}
+		if err := geoip.Load(gc.Path); err != nil {
+			utils.Logger.Error("加载 geoip 库失败", zap.String("path", gc.Path), zap.Error(err))
+			return
+		}
+		interval := time.Duration(gc.RefreshInterval) * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Minute
+		}
+		geoip.Watch(gc.Path, interval)
+	})
+}
+
+// HandleGeo 按客户端的国家/ASN 选择目标，找不到匹配项时回退到第一个未设置 Country/ASN 的目标。
+func HandleGeo(conn net.Conn, rule *config.Rule) {
+	defer conn.Close()
+
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+
+	var country string
+	var asn uint32
+	if ip := net.ParseIP(host); ip != nil {
+		if c, a, lerr := geoip.Lookup(ip); lerr == nil {
+			country, asn = c, a
+		} else {
+			utils.Logger.Warn("geoip 查询失败，按默认目标处理",
+				zap.String("ruleName", rule.Name),
+				zap.String("remoteAddr", conn.RemoteAddr().String()),
+				zap.Error(lerr))
+		}
+	}
+
+	matchedIdx, fallbackIdx := -1, -1
+	for i, v := range rule.Targets {
+		if len(v.Country) == 0 && len(v.ASN) == 0 {
+			if fallbackIdx == -1 {
+				fallbackIdx = i
+			}
+			continue
+		}
+		if matchGeoTarget(v.Country, v.ASN, country, asn) {
+			matchedIdx = i
+			break
+		}
+	}
+	if matchedIdx == -1 {
+		matchedIdx = fallbackIdx
+	}
+	if matchedIdx == -1 {
+		utils.Logger.Error("未匹配到地理位置目标，且无默认目标，无法处理连接",
+			zap.String("ruleName", rule.Name),
+			zap.String("remoteAddr", conn.RemoteAddr().String()),
+			zap.String("country", country))
+		return
+	}
+
+	v := rule.Targets[matchedIdx]
+	target, err := outboundDial(v.Address, v.SendProxy, conn.RemoteAddr(), conn.LocalAddr())
+	if err != nil {
+		utils.Logger.Error("无法建立连接",
+			zap.String("ruleName", rule.Name),
+			zap.String("remoteAddr", conn.RemoteAddr().String()),
+			zap.String("targetAddr", v.Address))
+		return
+	}
+	if tc, ok := target.(*net.TCPConn); ok {
+		_ = tc.SetNoDelay(true)
+		_ = tc.SetKeepAlive(true)
+		_ = tc.SetKeepAlivePeriod(30 * time.Second)
+	}
+	utils.Logger.Debug("建立连接",
+		zap.String("ruleName", rule.Name),
+		zap.String("remoteAddr", conn.RemoteAddr().String()),
+		zap.String("targetAddr", target.RemoteAddr().String()),
+		zap.String("country", country),
+		zap.Uint32("asn", asn))
+
+	defer target.Close()
+
+	go func() {
+		accountedCopy(rule.Name, conn, target, false)
+		conn.Close()
+		target.Close()
+	}()
+	accountedCopy(rule.Name, target, conn, true)
+}
+
+func matchGeoTarget(countries []string, asns []uint32, country string, asn uint32) bool {
+	for _, c := range countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"moto/config"
+	"moto/utils"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rendezvousScore 按 HRW（Rendezvous Hashing）算法计算单个目标的得分：
+// weight / -ln(hash(ip||address) / MaxUint64)，得分最高者当选。
+// 纯函数、无状态，每次连接即时计算，省去了 boost 缓存那一套过期/淘汰逻辑。
+func rendezvousScore(ip, address string, weight uint32) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(ip))
+	_, _ = h.Write([]byte(address))
+	sum := h.Sum64()
+	// sum 为 0 时 -ln(0) 发散，退化为最小得分，避免除零。
+	if sum == 0 {
+		return -math.MaxFloat64
+	}
+	if weight == 0 {
+		weight = 1
+	}
+	normalized := float64(sum) / float64(math.MaxUint64)
+	return float64(weight) / -math.Log(normalized)
+}
+
+type hashCandidate struct {
+	address string
+	score   float64
+}
+
+// rankTargets 返回按 HRW 得分从高到低排序的目标列表，供挑选主选及失败后的降级顺序使用。
+func rankTargets(rule *config.Rule, ip string) []hashCandidate {
+	candidates := make([]hashCandidate, len(rule.Targets))
+	for i, v := range rule.Targets {
+		var weight uint32 = 1
+		if i < len(rule.HashWeight) && rule.HashWeight[i] > 0 {
+			weight = rule.HashWeight[i]
+		}
+		candidates[i] = hashCandidate{address: v.Address, score: rendezvousScore(ip, v.Address, weight)}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	return candidates
+}
+
+// HandleHash 按客户端 IP 做一致性哈希（HRW）选择目标，使同一客户端尽量固定落到同一后端，
+// 适合后端保存会话状态的场景（如游戏服、WebRTC 信令）。选中目标拨号失败时按得分顺序降级，
+// 全部失败则退化到 HandleBoost。
+func HandleHash(conn net.Conn, rule *config.Rule) {
+	defer conn.Close()
+
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+
+	ranked := rankTargets(rule, host)
+	if len(ranked) == 0 {
+		utils.Logger.Error("hash: 规则没有可用目标",
+			zap.String("ruleName", rule.Name))
+		return
+	}
+
+	parts := make([]string, len(ranked))
+	for i, c := range ranked {
+		parts[i] = fmt.Sprintf("%s=%.4f", c.address, c.score)
+	}
+	utils.Logger.Debug("hash: 目标排名",
+		zap.String("ruleName", rule.Name),
+		zap.String("remoteAddr", conn.RemoteAddr().String()),
+		zap.String("ranked", strings.Join(parts, ",")))
+
+	for _, c := range ranked {
+		target, err := outboundDial(c.address, sendProxyFor(rule, c.address), conn.RemoteAddr(), conn.LocalAddr())
+		if err != nil {
+			utils.Logger.Warn("hash: 无法建立连接，尝试下一顺位目标",
+				zap.String("ruleName", rule.Name),
+				zap.String("remoteAddr", conn.RemoteAddr().String()),
+				zap.String("targetAddr", c.address))
+			continue
+		}
+		if tc, ok := target.(*net.TCPConn); ok {
+			_ = tc.SetNoDelay(true)
+			_ = tc.SetKeepAlive(true)
+			_ = tc.SetKeepAlivePeriod(30 * time.Second)
+		}
+		utils.Logger.Debug("建立连接",
+			zap.String("ruleName", rule.Name),
+			zap.String("remoteAddr", conn.RemoteAddr().String()),
+			zap.String("targetAddr", target.RemoteAddr().String()))
+
+		defer target.Close()
+
+		go func() {
+			accountedCopy(rule.Name, conn, target, false)
+			conn.Close()
+			target.Close()
+		}()
+		accountedCopy(rule.Name, target, conn, true)
+		return
+	}
+
+	utils.Logger.Error("hash: 所有目标均不可用，退化到 boost 模式",
+		zap.String("ruleName", rule.Name),
+		zap.String("remoteAddr", conn.RemoteAddr().String()))
+	HandleBoost(conn, rule)
+}
@@ -0,0 +1,221 @@
+// Package health 为 urltest / fallback 路由模式提供后台健康探测：
+// 每条规则一个轮询协程，周期性地探活所有目标并以 EWMA 平滑 RTT，
+// 结果通过原子指针整体替换，热路径读取时不需要加锁。
+package health
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"moto/config"
+	"moto/utils"
+
+	"go.uber.org/zap"
+)
+
+const ewmaAlpha = 0.3
+
+// atomicSnapshot 对 snapshot 做原子指针封装，热路径读取无需加锁。
+type atomicSnapshot struct {
+	p atomic.Pointer[snapshot]
+}
+
+func (a *atomicSnapshot) store(s snapshot) { a.p.Store(&s) }
+
+func (a *atomicSnapshot) load() snapshot {
+	if v := a.p.Load(); v != nil {
+		return *v
+	}
+	return snapshot{}
+}
+
+// Status 是单个目标的健康快照。
+type Status struct {
+	Address     string
+	RTT         time.Duration
+	ConsecFails int
+	Healthy     bool
+}
+
+// snapshot 是某一轮探测后的整体结果，与 rule.Targets 顺序一一对应。
+type snapshot struct {
+	list []Status
+}
+
+// Checker 负责一条规则下所有目标的周期性探活。
+type Checker struct {
+	rule *config.Rule
+	snap atomicSnapshot
+	stop chan struct{}
+}
+
+var (
+	checkers sync.Map // ruleName -> *Checker
+)
+
+// Start 为规则启动（或复用已存在的）健康探测协程，返回对应的 Checker。
+func Start(rule *config.Rule) *Checker {
+	if v, ok := checkers.Load(rule.Name); ok {
+		return v.(*Checker)
+	}
+	c := &Checker{rule: rule, stop: make(chan struct{})}
+	actual, loaded := checkers.LoadOrStore(rule.Name, c)
+	if loaded {
+		return actual.(*Checker)
+	}
+	initial := make([]Status, len(rule.Targets))
+	for i, t := range rule.Targets {
+		initial[i] = Status{Address: t.Address, Healthy: true}
+	}
+	c.snap.store(snapshot{list: initial})
+	go c.run()
+	return c
+}
+
+// Get 返回规则已注册的 Checker，未启动过探测时返回 nil。
+func Get(ruleName string) *Checker {
+	if v, ok := checkers.Load(ruleName); ok {
+		return v.(*Checker)
+	}
+	return nil
+}
+
+// Stop 终止规则对应的探测协程并将其从注册表中移除，供规则被 drain/删除时调用，
+// 避免 reload 移除一条 urltest/fallback 规则之后，其健康探测协程仍然对着已经不存在的目标探活下去。
+func Stop(ruleName string) {
+	v, ok := checkers.LoadAndDelete(ruleName)
+	if !ok {
+		return
+	}
+	close(v.(*Checker).stop)
+}
+
+func (c *Checker) run() {
+	interval := time.Duration(c.rule.HealthCheck.Interval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	c.probeAll()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.probeAll()
+		}
+	}
+}
+
+func (c *Checker) probeAll() {
+	targets := c.rule.Targets
+	results := make([]Status, len(targets))
+	prev := c.snap.load()
+
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for i, t := range targets {
+		go func(i int, addr string) {
+			defer wg.Done()
+			var prevStatus Status
+			if prev.list != nil && i < len(prev.list) {
+				prevStatus = prev.list[i]
+			} else {
+				prevStatus = Status{Address: addr, Healthy: true}
+			}
+			results[i] = c.probeOne(addr, prevStatus)
+		}(i, t.Address)
+	}
+	wg.Wait()
+
+	c.snap.store(snapshot{list: results})
+}
+
+func (c *Checker) probeOne(addr string, prev Status) Status {
+	timeout := time.Duration(c.rule.HealthCheck.ProbeTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	failThreshold := c.rule.HealthCheck.FailThreshold
+	if failThreshold <= 0 {
+		failThreshold = 3
+	}
+
+	start := time.Now()
+	ok := dialAndProbe(addr, c.rule.HealthCheck.Probe, timeout)
+	rtt := time.Since(start)
+
+	status := Status{Address: addr}
+	if ok {
+		status.ConsecFails = 0
+		status.Healthy = true
+		if prev.RTT <= 0 {
+			status.RTT = rtt
+		} else {
+			status.RTT = time.Duration(ewmaAlpha*float64(rtt) + (1-ewmaAlpha)*float64(prev.RTT))
+		}
+	} else {
+		status.RTT = prev.RTT
+		status.ConsecFails = prev.ConsecFails + 1
+		status.Healthy = status.ConsecFails < failThreshold
+		if !status.Healthy && prev.Healthy {
+			utils.Logger.Warn("目标连续探测失败，标记为不健康", zap.String("target", addr), zap.Int("consecFails", status.ConsecFails))
+		}
+	}
+	return status
+}
+
+func dialAndProbe(addr, probe string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if probe == "" {
+		return true
+	}
+	_ = conn.SetDeadline(deadline)
+	if _, err := conn.Write([]byte(probe)); err != nil {
+		return false
+	}
+	buf := make([]byte, 256)
+	_, err = conn.Read(buf)
+	return err == nil
+}
+
+// Snapshot 返回当前所有目标的健康状态，与 rule.Targets 顺序一致。
+func (c *Checker) Snapshot() []Status {
+	return c.snap.load().list
+}
+
+// BestByRTT 返回 RTT 最小的健康目标地址。
+func (c *Checker) BestByRTT() (string, bool) {
+	list := c.Snapshot()
+	best := -1
+	for i, s := range list {
+		if !s.Healthy {
+			continue
+		}
+		if best == -1 || s.RTT < list[best].RTT {
+			best = i
+		}
+	}
+	if best == -1 {
+		return "", false
+	}
+	return list[best].Address, true
+}
+
+// FirstHealthy 按声明顺序返回第一个健康目标地址。
+func (c *Checker) FirstHealthy() (string, bool) {
+	for _, s := range c.Snapshot() {
+		if s.Healthy {
+			return s.Address, true
+		}
+	}
+	return "", false
+}
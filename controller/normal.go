@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"io"
 	"moto/config"
 	"moto/utils"
 	"net"
@@ -14,10 +13,14 @@ import (
 func HandleNormal(conn net.Conn, rule *config.Rule) {
 	defer conn.Close()
 
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
 	var target net.Conn
 	//正常模式下挨个连接直到成功连接
 	for _, v := range rule.Targets {
-		c, err := outboundDial(v.Address)
+		c, err := outboundDial(v.Address, v.SendProxy, conn.RemoteAddr(), conn.LocalAddr())
 		if err != nil {
 			utils.Logger.Error("无法建立连接，尝试下一个目标",
 				zap.String("ruleName", rule.Name),
@@ -47,9 +50,9 @@ func HandleNormal(conn net.Conn, rule *config.Rule) {
 	defer target.Close()
 
 	go func() {
-		io.Copy(conn, target)
+		accountedCopy(rule.Name, conn, target, false)
 		conn.Close()
 		target.Close()
 	}()
-	io.Copy(target, conn)
+	accountedCopy(rule.Name, target, conn, true)
 }
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -39,6 +40,11 @@ func initPrewarm(rule *config.Rule) {
 	}
 	desired := prewarmInitialSize
 	for _, target := range rule.Targets {
+		if target.Transport == "quic" || target.Transport == "tcp+tls" {
+			// QUIC 目标走共享会话 + 新建 stream；tcp+tls 每次都要重新握手，
+			// 两者都没有可以入池复用的空闲明文 TCP 连接。
+			continue
+		}
 		ensurePrewarmPool(target.Address, desired)
 	}
 }
@@ -143,15 +149,54 @@ func acquirePrewarmed(addr string) (net.Conn, bool) {
 	return conn, true
 }
 
-// outboundDial 先尝试预热池，失败再发起新建连接。
+// ResizePrewarmPool 供 commander 调用，运行时调整指定目标地址预热池的 desired 连接数。
+func ResizePrewarmPool(addr string, desired int) bool {
+	poolAny, ok := prewarmPools.Load(addr)
+	if !ok {
+		return false
+	}
+	pool := poolAny.(*prewarmPool)
+	pool.mu.Lock()
+	if desired > prewarmPerTargetMax {
+		desired = prewarmPerTargetMax
+	}
+	pool.desired = desired
+	pool.ensureLocked()
+	pool.mu.Unlock()
+	return true
+}
+
+// outboundDial 先尝试预热池，失败再发起新建连接；sendProxy 为 true 时在连接到手后立即写出
+// PROXY protocol v2 头部。预热池里的空闲连接在拨号阶段还不知道真实客户端是谁，因此永远不会
+// 带着头部入池——头部统一在这里、拿到连接之后才"刷出"，不管这个连接是刚拨的号还是从池子里取的。
 // 之前返回 (conn, usedFlag, error)，由于当前不再区分来源，精简为 (conn, error)。
-func outboundDial(addr string) (net.Conn, error) {
-	if conn, ok := acquirePrewarmed(addr); ok {
-		return conn, nil
+func outboundDial(addr string, sendProxy bool, src, dst net.Addr) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	switch transportFor(addr) {
+	case "quic":
+		conn, err = DialQUIC(addr)
+	case "tcp+tls":
+		conn, err = DialFastTLS(addr)
+	default:
+		if c, ok := acquirePrewarmed(addr); ok {
+			conn = c
+		} else {
+			conn, err = DialFast(addr)
+		}
 	}
-	c, err := DialFast(addr)
 	if err != nil {
 		return nil, err
 	}
-	return c, nil
+	if sendProxy {
+		header, herr := buildProxyV2Header(src, dst)
+		if herr != nil {
+			utils.Logger.Warn("proxy protocol: 无法构造 v2 头部，跳过发送",
+				zap.String("targetAddr", addr), zap.Error(herr))
+		} else if _, werr := conn.Write(header); werr != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy protocol: 写入 v2 头部失败: %w", werr)
+		}
+	}
+	return conn, nil
 }
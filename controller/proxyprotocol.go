@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"moto/config"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	proxyV1MaxHeaderLen = 107 // PROXY protocol v1 规定的最大头部长度
+	proxyV2MaxHeaderLen = 256 // 12 字节签名 + 4 字节定长头 + 地址块/TLV，留足余量
+	proxyReadDeadline   = 500 * time.Millisecond
+)
+
+// proxyV2Signature 是 PROXY protocol v2 头部固定的 12 字节魔数。
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// proxyProtoConn 包装已接受的连接，用解析出的真实客户端/原始目的地址覆盖 RemoteAddr/LocalAddr，
+// Read 改为从 reader（通常是已消费完头部的 bufio.Reader）读取，避免吞掉头部之后的业务数据。
+type proxyProtoConn struct {
+	net.Conn
+	reader     io.Reader
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (p *proxyProtoConn) Read(b []byte) (int, error) { return p.reader.Read(b) }
+
+func (p *proxyProtoConn) RemoteAddr() net.Addr {
+	if p.remoteAddr != nil {
+		return p.remoteAddr
+	}
+	return p.Conn.RemoteAddr()
+}
+
+func (p *proxyProtoConn) LocalAddr() net.Addr {
+	if p.localAddr != nil {
+		return p.localAddr
+	}
+	return p.Conn.LocalAddr()
+}
+
+// acceptProxyProtocol 按规则配置的模式在分发前解析 PROXY protocol 头部，
+// 成功后返回的 net.Conn 的 RemoteAddr/LocalAddr 已替换为头部中携带的真实地址，
+// 后续黑名单匹配、WAF 限速、geo/hash 选路都能看到真实来源 IP。
+func acceptProxyProtocol(conn net.Conn, mode string) (net.Conn, error) {
+	if mode == "" || mode == "none" {
+		return conn, nil
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(proxyReadDeadline))
+	defer conn.SetReadDeadline(time.Time{})
+
+	br := bufio.NewReaderSize(conn, proxyV2MaxHeaderLen)
+	sig, peekErr := br.Peek(len(proxyV2Signature))
+	isV2 := peekErr == nil && string(sig) == string(proxyV2Signature)
+
+	switch mode {
+	case "accept-v1":
+		if isV2 {
+			return nil, fmt.Errorf("proxy protocol: 期望 v1 头部，但检测到 v2 签名")
+		}
+		return parseProxyV1(conn, br)
+	case "accept-v2":
+		if !isV2 {
+			return nil, fmt.Errorf("proxy protocol: 未检测到 v2 签名")
+		}
+		return parseProxyV2(conn, br)
+	case "accept-any":
+		if isV2 {
+			return parseProxyV2(conn, br)
+		}
+		return parseProxyV1(conn, br)
+	default:
+		return conn, nil
+	}
+}
+
+// parseProxyV1 解析文本格式的 PROXY v1 头部："PROXY TCP4 <src> <dst> <srcPort> <dstPort>\r\n"。
+// 逐字节读取并以 proxyV1MaxHeaderLen 硬性封顶，而不是用 br.ReadString('\n') ——
+// 后者在找到换行符之前会无限制地把数据攒进内部缓冲区，一个故意不发 '\n' 的客户端
+// 可以借此让每个连接在读超时触发前占用任意大小的内存。
+func parseProxyV1(conn net.Conn, br *bufio.Reader) (net.Conn, error) {
+	buf := make([]byte, 0, proxyV1MaxHeaderLen)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("proxy protocol v1: 读取头部失败: %w", err)
+		}
+		buf = append(buf, b)
+		if b == '\n' {
+			break
+		}
+		if len(buf) >= proxyV1MaxHeaderLen {
+			return nil, fmt.Errorf("proxy protocol v1: 头部超过 %d 字节仍未找到换行符", proxyV1MaxHeaderLen)
+		}
+	}
+	line := strings.TrimRight(string(buf), "\r\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("proxy protocol v1: 格式错误: %q", line)
+	}
+
+	wrapped := &proxyProtoConn{Conn: conn, reader: br}
+	if fields[1] == "UNKNOWN" {
+		return wrapped, nil
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("proxy protocol v1: 格式错误: %q", line)
+	}
+	srcIP := net.ParseIP(fields[2])
+	dstIP := net.ParseIP(fields[3])
+	srcPort, perr1 := strconv.Atoi(fields[4])
+	dstPort, perr2 := strconv.Atoi(fields[5])
+	if srcIP == nil || dstIP == nil || perr1 != nil || perr2 != nil {
+		return nil, fmt.Errorf("proxy protocol v1: 地址字段非法: %q", line)
+	}
+	wrapped.remoteAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+	wrapped.localAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	return wrapped, nil
+}
+
+// parseProxyV2 解析二进制格式的 PROXY v2 头部：12 字节签名 + ver_cmd + fam + 2 字节地址块长度 + 地址块/TLV。
+func parseProxyV2(conn net.Conn, br *bufio.Reader) (net.Conn, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		return nil, fmt.Errorf("proxy protocol v2: 读取定长头失败: %w", err)
+	}
+	verCmd := fixed[12]
+	if verCmd>>4 != 0x2 {
+		return nil, fmt.Errorf("proxy protocol v2: 不支持的版本号 %#x", verCmd>>4)
+	}
+	cmd := verCmd & 0x0F
+	fam := fixed[13] >> 4
+	addrLen := int(binary.BigEndian.Uint16(fixed[14:16]))
+	if addrLen > proxyV2MaxHeaderLen-16 {
+		return nil, fmt.Errorf("proxy protocol v2: 地址块过长 %d 字节", addrLen)
+	}
+	body := make([]byte, addrLen)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, fmt.Errorf("proxy protocol v2: 读取地址块失败: %w", err)
+	}
+
+	wrapped := &proxyProtoConn{Conn: conn, reader: br}
+	if cmd == 0x0 {
+		// LOCAL：健康检查等场景，沿用连接本身的地址。
+		return wrapped, nil
+	}
+	switch fam {
+	case 0x1: // AF_INET
+		if addrLen < 12 {
+			return nil, fmt.Errorf("proxy protocol v2: IPv4 地址块过短 %d 字节", addrLen)
+		}
+		wrapped.remoteAddr = &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}
+		wrapped.localAddr = &net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:12]))}
+	case 0x2: // AF_INET6
+		if addrLen < 36 {
+			return nil, fmt.Errorf("proxy protocol v2: IPv6 地址块过短 %d 字节", addrLen)
+		}
+		wrapped.remoteAddr = &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}
+		wrapped.localAddr = &net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:36]))}
+	default:
+		// AF_UNSPEC / AF_UNIX：协议允许但这里不关心，沿用原始地址。
+	}
+	return wrapped, nil
+}
+
+// sendProxyFor 在规则的 Targets 中查找 addr 对应的 SendProxy 设置，找不到时按不发送处理。
+func sendProxyFor(rule *config.Rule, addr string) bool {
+	for _, v := range rule.Targets {
+		if v.Address == addr {
+			return v.SendProxy
+		}
+	}
+	return false
+}
+
+// buildProxyV2Header 构造一个携带 src/dst 地址的 PROXY v2 头部，供 SendProxy 目标在转发业务数据前写出。
+func buildProxyV2Header(src, dst net.Addr) ([]byte, error) {
+	srcTCP, ok := src.(*net.TCPAddr)
+	if !ok || srcTCP.IP == nil {
+		return nil, fmt.Errorf("proxy protocol v2: src 不是合法的 TCP 地址")
+	}
+	dstTCP, ok := dst.(*net.TCPAddr)
+	if !ok || dstTCP.IP == nil {
+		return nil, fmt.Errorf("proxy protocol v2: dst 不是合法的 TCP 地址")
+	}
+
+	var fam byte
+	var body []byte
+	if srcIP4, dstIP4 := srcTCP.IP.To4(), dstTCP.IP.To4(); srcIP4 != nil && dstIP4 != nil {
+		fam = 0x1
+		body = make([]byte, 12)
+		copy(body[0:4], srcIP4)
+		copy(body[4:8], dstIP4)
+		binary.BigEndian.PutUint16(body[8:10], uint16(srcTCP.Port))
+		binary.BigEndian.PutUint16(body[10:12], uint16(dstTCP.Port))
+	} else if srcIP6, dstIP6 := srcTCP.IP.To16(), dstTCP.IP.To16(); srcIP6 != nil && dstIP6 != nil {
+		fam = 0x2
+		body = make([]byte, 36)
+		copy(body[0:16], srcIP6)
+		copy(body[16:32], dstIP6)
+		binary.BigEndian.PutUint16(body[32:34], uint16(srcTCP.Port))
+		binary.BigEndian.PutUint16(body[34:36], uint16(dstTCP.Port))
+	} else {
+		return nil, fmt.Errorf("proxy protocol v2: src/dst 地址族不匹配")
+	}
+
+	header := make([]byte, 0, 16+len(body))
+	header = append(header, proxyV2Signature...)
+	header = append(header, 0x21)       // version=2, command=PROXY
+	header = append(header, fam<<4|0x1) // family | SOCK_STREAM
+	lenBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(lenBuf, uint16(len(body)))
+	header = append(header, lenBuf...)
+	header = append(header, body...)
+	return header, nil
+}
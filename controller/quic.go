@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"moto/config"
+	"moto/utils"
+
+	"github.com/quic-go/quic-go"
+	"go.uber.org/zap"
+)
+
+var targetTransports sync.Map // 目标地址 -> 传输方式（tcp/quic/tcp+tls）
+
+// registerTargetTransports 记录规则下每个目标的传输方式，供 outboundDial 决定走哪条拨号路径。
+func registerTargetTransports(rule *config.Rule) {
+	for _, t := range rule.Targets {
+		transport := t.Transport
+		if transport == "" {
+			transport = "tcp"
+		}
+		targetTransports.Store(t.Address, transport)
+	}
+}
+
+func transportFor(addr string) string {
+	if v, ok := targetTransports.Load(addr); ok {
+		return v.(string)
+	}
+	return "tcp"
+}
+
+// quicConn 把一个 quic.Stream 包装成 net.Conn，地址信息取自其所属的 quic.Connection。
+type quicConn struct {
+	quic.Stream
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *quicConn) LocalAddr() net.Addr  { return c.localAddr }
+func (c *quicConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+func newQUICConn(stream quic.Stream, session quic.Connection) net.Conn {
+	return &quicConn{Stream: stream, localAddr: session.LocalAddr(), remoteAddr: session.RemoteAddr()}
+}
+
+// listenQUIC 以 QUIC 监听规则端口，accept 到的每个 stream 都包装成 net.Conn 后复用既有的黑名单/WAF/模式分发逻辑。
+func listenQUIC(rule *config.Rule, ctx context.Context, stat *ruleStat) {
+	tlsConf, err := quicListenerTLSConfig()
+	if err != nil {
+		utils.Logger.Error(rule.Name+" failed to build TLS config for quic listener", zap.Error(err))
+		return
+	}
+	listener, err := quic.ListenAddr(rule.Listen, tlsConf, nil)
+	if err != nil {
+		utils.Logger.Error(rule.Name + " failed to listen(quic) at " + rule.Listen)
+		return
+	}
+	utils.Logger.Info(rule.Name + " listening(quic) at " + rule.Listen)
+
+	go func() {
+		<-ctx.Done()
+		utils.Logger.Info(rule.Name + " draining, stop accepting new quic connections")
+		listener.Close()
+	}()
+
+	for {
+		session, err := listener.Accept(context.Background())
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			utils.Logger.Error(rule.Name + " failed to accept(quic) at " + rule.Listen)
+			time.Sleep(time.Second)
+			continue
+		}
+		go acceptQUICStreams(session, rule, stat)
+	}
+}
+
+// acceptQUICStreams 从一个 quic 会话里持续接受双向 stream，每个 stream 等价于一条新连接。
+func acceptQUICStreams(session quic.Connection, rule *config.Rule, stat *ruleStat) {
+	for {
+		stream, err := session.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		conn := newQUICConn(stream, session)
+		if rule.ProxyProtocol != "" && rule.ProxyProtocol != "none" {
+			wrapped, perr := acceptProxyProtocol(conn, rule.ProxyProtocol)
+			if perr != nil {
+				utils.Logger.Warn(rule.Name+" 丢弃 quic stream：解析 PROXY protocol 头部失败",
+					zap.String("remoteAddr", conn.RemoteAddr().String()),
+					zap.Error(perr))
+				conn.Close()
+				continue
+			}
+			conn = wrapped
+		}
+		dispatch(conn, rule, stat)
+	}
+}
+
+func quicListenerTLSConfig() (*tls.Config, error) {
+	qc := config.GlobalCfg.QUIC
+	cert, err := tls.LoadX509KeyPair(qc.CertFile, qc.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"moto"},
+	}, nil
+}
+
+var quicSessions sync.Map // 目标地址 -> quic.Connection，按目标复用会话
+
+// DialQUIC 为 addr 复用（或新建）一个 QUIC 会话，并在其上打开一个新 stream 作为本次连接。
+func DialQUIC(addr string) (net.Conn, error) {
+	session, err := quicSession(addr)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := session.OpenStreamSync(context.Background())
+	if err != nil {
+		// 会话可能已失效，丢弃缓存后重新拨号一次
+		quicSessions.Delete(addr)
+		session, err = quicSession(addr)
+		if err != nil {
+			return nil, err
+		}
+		stream, err = session.OpenStreamSync(context.Background())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newQUICConn(stream, session), nil
+}
+
+func quicSession(addr string) (quic.Connection, error) {
+	if v, ok := quicSessions.Load(addr); ok {
+		return v.(quic.Connection), nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	tlsConf, err := egressTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf.NextProtos = []string{"moto"}
+	session, err := quic.DialAddr(context.Background(), addr, tlsConf, nil)
+	if err != nil {
+		return nil, err
+	}
+	actual, loaded := quicSessions.LoadOrStore(addr, session)
+	if loaded {
+		session.CloseWithError(0, "")
+		return actual.(quic.Connection), nil
+	}
+	return session, nil
+}
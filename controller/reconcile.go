@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"sync"
+
+	"moto/config"
+	"moto/utils"
+
+	"go.uber.org/zap"
+)
+
+// ReconcileRules 把 desired（通常是 commander Reload 新解析出的规则集合）与当前正在运行的规则对比：
+//   - 名字在两边都存在的规则，原地把可变字段更新到仍在运行的 *config.Rule 上，继续复用同一个
+//     Listen goroutine，commander 后续的 BlacklistAdd 等操作也就作用在这个活对象上；
+//   - 只在 desired 里出现的名字是新规则，立即拉起一个新的 Listen goroutine；
+//   - 只在运行中出现、desired 里没有的名字会被 DrainRule，不再出现在返回结果里。
+//
+// Listen/Transport 变更无法热切换监听端口，这里只原地更新其余字段并打日志提示，
+// 需要运维手动 drain 旧规则、等待其退出后再把新规则加回配置来让端口变更生效。
+//
+// 返回值是应当写回 config.GlobalCfg.Rules 的规则集合，调用方负责持久化/替换。
+func ReconcileRules(desired []*config.Rule, wg *sync.WaitGroup) []*config.Rule {
+	result := make([]*config.Rule, 0, len(desired))
+	seen := make(map[string]bool, len(desired))
+
+	for _, want := range desired {
+		seen[want.Name] = true
+		liveAny, ok := ruleRegistry.Load(want.Name)
+		if !ok {
+			wg.Add(1)
+			go Listen(want, wg)
+			result = append(result, want)
+			continue
+		}
+		live := liveAny.(*config.Rule)
+		if live.Listen != want.Listen || live.Transport != want.Transport {
+			utils.Logger.Warn("reload: 规则监听地址或传输协议发生变更，需手动 drain 该规则后等待其退出，变更才能生效",
+				zap.String("ruleName", want.Name))
+		}
+		live.Mode = want.Mode
+		live.Prewarm = want.Prewarm
+		live.ProxyProtocol = want.ProxyProtocol
+		live.Targets = want.Targets
+		registerTargetTransports(live)
+		live.Timeout = want.Timeout
+		live.BlacklistReplace(want.Blacklist)
+		live.HealthCheck = want.HealthCheck
+		live.HashWeight = want.HashWeight
+		result = append(result, live)
+	}
+
+	ruleRegistry.Range(func(k, _ any) bool {
+		name := k.(string)
+		if !seen[name] {
+			utils.Logger.Info("reload: 规则已从配置中移除，触发 drain", zap.String("ruleName", name))
+			DrainRule(name)
+		}
+		return true
+	})
+
+	return result
+}
@@ -14,6 +14,10 @@ import (
 func HandleRegexp(conn net.Conn, rule *config.Rule) {
 	defer conn.Close()
 
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
 	//正则模式下需要客户端的第一个数据包判断特征，所以需要设置一个超时
 	conn.SetReadDeadline(time.Now().Add(time.Millisecond * time.Duration(rule.Timeout)))
 	//获取第一个数据包
@@ -32,7 +36,7 @@ func HandleRegexp(conn net.Conn, rule *config.Rule) {
 		if !v.Re.Match(firstPacket.Bytes()) {
 			continue
 		}
-		c, used, err := DialAccelerated(v.Address)
+		c, err := outboundDial(v.Address, v.SendProxy, conn.RemoteAddr(), conn.LocalAddr())
 		if err != nil {
 			utils.Logger.Error("无法建立连接",
 				zap.String("ruleName", rule.Name),
@@ -40,11 +44,7 @@ func HandleRegexp(conn net.Conn, rule *config.Rule) {
 				zap.String("targetAddr", v.Address))
 			continue
 		}
-		if !used {
-			target = newOneSidedConn(c)
-		} else {
-			target = c
-		}
+		target = c
 		break
 	}
 	if target == nil {
@@ -66,9 +66,9 @@ func HandleRegexp(conn net.Conn, rule *config.Rule) {
 	defer target.Close()
 
 	go func() {
-		io.Copy(conn, target)
+		accountedCopy(rule.Name, conn, target, false)
 		conn.Close()
 		target.Close()
 	}()
-	io.Copy(target, conn)
+	accountedCopy(rule.Name, target, conn, true)
 }
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"io"
 	"moto/config"
 	"moto/utils"
 	"net"
@@ -17,15 +16,20 @@ var tcpCounter uint64
 func HandleRoundrobin(conn net.Conn, rule *config.Rule) {
 	defer conn.Close()
 
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
 	index := atomic.AddUint64(&tcpCounter, 1) % uint64(len(rule.Targets))
 	if tcpCounter >= 100*uint64(len(rule.Targets)) {
 		atomic.StoreUint64(&tcpCounter, 1)
 	}
+	stat.roundRobinI.Store(int64(index))
 
 	v := rule.Targets[index]
 
 	roundrobinBegin := time.Now()
-	target, err := outboundDial(v.Address)
+	target, err := outboundDial(v.Address, v.SendProxy, conn.RemoteAddr(), conn.LocalAddr())
 	if err != nil {
 		utils.Logger.Error("无法建立连接，切换到 boost 模式",
 			zap.String("ruleName", rule.Name),
@@ -49,9 +53,9 @@ func HandleRoundrobin(conn net.Conn, rule *config.Rule) {
 	defer target.Close()
 
 	go func() {
-		io.Copy(conn, target)
+		accountedCopy(rule.Name, conn, target, false)
 		conn.Close()
 		target.Close()
 	}()
-	io.Copy(target, conn)
+	accountedCopy(rule.Name, target, conn, true)
 }
@@ -2,21 +2,81 @@ package controller
 
 import (
 	"moto/config"
+	"moto/controller/health"
 	"moto/utils"
+	"moto/utils/geoip"
 	"net"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
 )
 
 var ipCache = cache.New(30*time.Second, 1*time.Minute)
 
-// Listen 根据规则启动 TCP 监听，做基础限流并分发到对应模式。
+// needsGeoIP 判断规则是否需要依赖 geoip 库：geo 模式本身或黑名单里存在国家代码条目。
+func needsGeoIP(rule *config.Rule) bool {
+	if rule.Mode == "geo" {
+		return true
+	}
+	for _, k := range rule.BlacklistKeys() {
+		if len(k) == 2 && !strings.Contains(k, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchBlacklist 判断 ip 是否命中黑名单：精确 IP、CIDR 段或国家代码三种条目均可命中。
+func matchBlacklist(rule *config.Rule, ip string) bool {
+	if rule.BlacklistHas(ip) {
+		return true
+	}
+	parsedIP := net.ParseIP(ip)
+	for _, k := range rule.BlacklistKeys() {
+		if strings.Contains(k, "/") {
+			if _, cidr, err := net.ParseCIDR(k); err == nil && parsedIP != nil && cidr.Contains(parsedIP) {
+				return true
+			}
+			continue
+		}
+		if len(k) == 2 && parsedIP != nil {
+			// 国家代码条目可能由 commander 或 reload 在运行时加入，此时 Listen 启动时未必加载过 geoip 库。
+			initGeoIP()
+			if country, _, err := geoip.Lookup(parsedIP); err == nil && strings.EqualFold(country, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Listen 根据规则启动监听（tcp 或 quic），做基础限流并分发到对应模式。
 func Listen(rule *config.Rule, wg *sync.WaitGroup) {
 	defer wg.Done()
+	// 登记活对象，供 ReconcileRules 在 Reload 时原地更新配置，而不是去操作新解析出的孤儿规则。
+	ruleRegistry.Store(rule.Name, rule)
+	defer ruleRegistry.Delete(rule.Name)
+	registerTargetTransports(rule)
 	initPrewarm(rule)
+	if needsGeoIP(rule) {
+		initGeoIP()
+	}
+	if rule.Mode == "urltest" || rule.Mode == "fallback" {
+		health.Start(rule)
+	}
+
+	//注册可被 commander 取消的 context，用于远程触发 drain
+	ctx := registerRuleControl(rule.Name)
+	stat := statsFor(rule.Name)
+
+	if rule.Transport == "quic" {
+		listenQUIC(rule, ctx, stat)
+		return
+	}
+
 	//监听
 	listener, err := net.Listen("tcp", rule.Listen)
 	if err != nil {
@@ -24,48 +84,85 @@ func Listen(rule *config.Rule, wg *sync.WaitGroup) {
 		return
 	}
 	utils.Logger.Info(rule.Name + " listing at " + rule.Listen)
+
+	go func() {
+		<-ctx.Done()
+		utils.Logger.Info(rule.Name + " draining, stop accepting new connections")
+		listener.Close()
+	}()
+
 	for {
 		//处理客户端连接
 		conn, err := listener.Accept()
 		if err != nil {
+			if ctx.Err() != nil {
+				//规则已被 drain，正常退出 accept 循环
+				return
+			}
 			utils.Logger.Error(rule.Name + " failed to accept at " + rule.Listen)
 			time.Sleep(time.Second * 1)
 			continue
 		}
-		//判断黑名单
-		if len(rule.Blacklist) != 0 {
-			clientIP := conn.RemoteAddr().String()
-			clientIP = clientIP[0:strings.LastIndex(clientIP, ":")]
-			if rule.Blacklist[clientIP] {
-				utils.Logger.Info(rule.Name + " disconnected ip in blacklist: " + clientIP)
+		if rule.ProxyProtocol != "" && rule.ProxyProtocol != "none" {
+			wrapped, perr := acceptProxyProtocol(conn, rule.ProxyProtocol)
+			if perr != nil {
+				utils.Logger.Warn(rule.Name+" 丢弃连接：解析 PROXY protocol 头部失败",
+					zap.String("remoteAddr", conn.RemoteAddr().String()),
+					zap.Error(perr))
 				conn.Close()
 				continue
 			}
+			conn = wrapped
 		}
-		//todo: WAF策略：限制单一IP 30秒内请求不能超过200次, no debug,wait fix
+		dispatch(conn, rule, stat)
+	}
+}
+
+// dispatch 对单个已接受的连接执行黑名单/WAF 检查，并分发到对应模式的处理函数。
+func dispatch(conn net.Conn, rule *config.Rule, stat *ruleStat) {
+	//判断黑名单：支持裸 IP、CIDR 段、国家代码
+	if rule.BlacklistLen() != 0 {
 		clientIP := conn.RemoteAddr().String()
 		clientIP = clientIP[0:strings.LastIndex(clientIP, ":")]
-		if count, found := ipCache.Get(clientIP); found && count.(int) >= 200 {
-			utils.Logger.Warn("WAF: too many requests from " + clientIP)
+		if matchBlacklist(rule, clientIP) {
+			utils.Logger.Info(rule.Name + " disconnected ip in blacklist: " + clientIP)
 			conn.Close()
-			continue
-		} else {
-			if found {
-				ipCache.Increment(clientIP, 1)
-			} else {
-				ipCache.Set(clientIP, 1, cache.DefaultExpiration)
-			}
+			return
 		}
-		//选择运行模式
-		switch rule.Mode {
-		case "normal":
-			go HandleNormal(conn, rule)
-		case "regex":
-			go HandleRegexp(conn, rule)
-		case "boost":
-			go HandleBoost(conn, rule)
-		case "roundrobin":
-			go HandleRoundrobin(conn, rule)
+	}
+	//todo: WAF策略：限制单一IP 30秒内请求不能超过200次, no debug,wait fix
+	clientIP := conn.RemoteAddr().String()
+	clientIP = clientIP[0:strings.LastIndex(clientIP, ":")]
+	if count, found := ipCache.Get(clientIP); found && count.(int) >= 200 {
+		utils.Logger.Warn("WAF: too many requests from " + clientIP)
+		stat.wafDropped.Add(1)
+		conn.Close()
+		return
+	} else {
+		if found {
+			ipCache.Increment(clientIP, 1)
+		} else {
+			ipCache.Set(clientIP, 1, cache.DefaultExpiration)
 		}
 	}
+	stat.accepted.Add(1)
+	//选择运行模式
+	switch rule.Mode {
+	case "normal":
+		go HandleNormal(conn, rule)
+	case "regex":
+		go HandleRegexp(conn, rule)
+	case "boost":
+		go HandleBoost(conn, rule)
+	case "roundrobin":
+		go HandleRoundrobin(conn, rule)
+	case "geo":
+		go HandleGeo(conn, rule)
+	case "urltest":
+		go HandleURLTest(conn, rule)
+	case "fallback":
+		go HandleFallback(conn, rule)
+	case "hash":
+		go HandleHash(conn, rule)
+	}
 }
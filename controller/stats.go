@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"moto/controller/health"
+)
+
+// ruleStat 保存单条规则的运行时计数器，供 commander 的统计接口读取。
+type ruleStat struct {
+	accepted    atomic.Int64
+	active      atomic.Int64
+	bytesIn     atomic.Int64 // 客户端 -> 目标
+	bytesOut    atomic.Int64 // 目标 -> 客户端
+	wafDropped  atomic.Int64
+	boostHit    atomic.Int64
+	boostMiss   atomic.Int64
+	roundRobinI atomic.Int64
+}
+
+var ruleStats sync.Map // ruleName -> *ruleStat
+
+func statsFor(ruleName string) *ruleStat {
+	v, _ := ruleStats.LoadOrStore(ruleName, &ruleStat{})
+	return v.(*ruleStat)
+}
+
+// ruleControl 保存每条规则的生命周期控制：cancel 用于 commander 触发 drain/stop。
+type ruleControl struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var ruleControls sync.Map // ruleName -> *ruleControl
+
+// registerRuleControl 为规则建立可被 commander 取消的 context，返回其 context 供 Listen 的 accept 循环选择。
+func registerRuleControl(ruleName string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	ruleControls.Store(ruleName, &ruleControl{ctx: ctx, cancel: cancel})
+	return ctx
+}
+
+// ruleRegistry 记录每条当前正在运行的规则名对应的、真正被其 Listen goroutine 持有的 *config.Rule。
+// ReconcileRules 靠它判断一条规则是“继续运行、原地更新配置”还是“全新规则，需要拉起监听”，
+// commander 的 BlacklistAdd 等写操作也通过它（经由 config.GlobalCfg.Rules 回填）作用在活对象上，
+// 而不是 Reload 新解析出来、没有任何 goroutine 在读的孤儿对象。
+var ruleRegistry sync.Map // ruleName -> *config.Rule
+
+// DrainRule 触发规则的 accept 循环停止接收新连接，已建立的连接不受影响；
+// 同时停掉该规则可能存在的后台健康探测协程，避免规则被 reload 移除之后它还在对着旧目标探活。
+func DrainRule(ruleName string) bool {
+	v, ok := ruleControls.Load(ruleName)
+	if !ok {
+		return false
+	}
+	v.(*ruleControl).cancel()
+	health.Stop(ruleName)
+	return true
+}
+
+// RuleStatsSnapshot 是 ruleStat 在某一时刻的只读快照，供 commander 等外部控制接口读取。
+type RuleStatsSnapshot struct {
+	Accepted      int64
+	Active        int64
+	BytesIn       int64
+	BytesOut      int64
+	WAFDropped    int64
+	BoostHit      int64
+	BoostMiss     int64
+	RoundRobinIdx int64
+}
+
+// StatsSnapshot 返回规则当前的统计快照；规则尚未出现过流量时返回零值。
+func StatsSnapshot(ruleName string) RuleStatsSnapshot {
+	v, ok := ruleStats.Load(ruleName)
+	if !ok {
+		return RuleStatsSnapshot{}
+	}
+	s := v.(*ruleStat)
+	return RuleStatsSnapshot{
+		Accepted:      s.accepted.Load(),
+		Active:        s.active.Load(),
+		BytesIn:       s.bytesIn.Load(),
+		BytesOut:      s.bytesOut.Load(),
+		WAFDropped:    s.wafDropped.Load(),
+		BoostHit:      s.boostHit.Load(),
+		BoostMiss:     s.boostMiss.Load(),
+		RoundRobinIdx: s.roundRobinI.Load(),
+	}
+}
+
+// accountedCopy 在 io.Copy 基础上按方向累加规则的字节计数，供统计接口展示吞吐。
+func accountedCopy(ruleName string, dst, src net.Conn, toTarget bool) {
+	stat := statsFor(ruleName)
+	n, _ := io.Copy(dst, src)
+	if toTarget {
+		stat.bytesIn.Add(n)
+	} else {
+		stat.bytesOut.Add(n)
+	}
+}
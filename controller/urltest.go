@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"moto/config"
+	"moto/controller/health"
+	"moto/utils"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HandleURLTest 始终转发到当前延迟最低的健康目标，全员不健康时退化到 HandleBoost。
+func HandleURLTest(conn net.Conn, rule *config.Rule) {
+	defer conn.Close()
+
+	stat := statsFor(rule.Name)
+	stat.active.Add(1)
+	defer stat.active.Add(-1)
+
+	checker := health.Start(rule)
+	addr, ok := checker.BestByRTT()
+	if !ok {
+		utils.Logger.Warn("urltest: 所有目标均不健康，退化到 boost 模式",
+			zap.String("ruleName", rule.Name))
+		HandleBoost(conn, rule)
+		return
+	}
+
+	target, err := outboundDial(addr, sendProxyFor(rule, addr), conn.RemoteAddr(), conn.LocalAddr())
+	if err != nil {
+		utils.Logger.Error("urltest: 无法建立连接，退化到 boost 模式",
+			zap.String("ruleName", rule.Name),
+			zap.String("targetAddr", addr))
+		HandleBoost(conn, rule)
+		return
+	}
+	if tc, ok := target.(*net.TCPConn); ok {
+		_ = tc.SetNoDelay(true)
+		_ = tc.SetKeepAlive(true)
+		_ = tc.SetKeepAlivePeriod(30 * time.Second)
+	}
+	utils.Logger.Debug("建立连接",
+		zap.String("ruleName", rule.Name),
+		zap.String("remoteAddr", conn.RemoteAddr().String()),
+		zap.String("targetAddr", target.RemoteAddr().String()))
+
+	defer target.Close()
+
+	go func() {
+		accountedCopy(rule.Name, conn, target, false)
+		conn.Close()
+		target.Close()
+	}()
+	accountedCopy(rule.Name, target, conn, true)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"moto/config"
 	"moto/controller"
+	"moto/controller/commander"
 	"moto/utils"
 	"os"
 	"sync"
@@ -27,6 +28,7 @@ func main() {
 	utils.Logger.Info("MOTO 启动...")
 	// single-sided build: no accelerator init required
 	wg := &sync.WaitGroup{}
+	go commander.Serve(config.GlobalCfg.Commander, wg)
 	for _, v := range config.GlobalCfg.Rules {
 		wg.Add(1)
 		go controller.Listen(v, wg)
@@ -0,0 +1,101 @@
+// Package geoip 提供基于本地 MaxMind .mmdb 文件的 IP 归属地/ASN 查询，
+// 供 geo 路由模式以及黑名单的国家代码匹配使用。
+package geoip
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"moto/utils"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+)
+
+// record 对应 mmdb 中我们关心的字段子集（GeoLite2-City/ASN 均兼容）。
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	AutonomousSystemNumber uint32 `maxminddb:"autonomous_system_number"`
+}
+
+type lookupResult struct {
+	country string
+	asn     uint32
+}
+
+var (
+	errNotLoaded = errors.New("geoip: 数据库尚未加载")
+
+	reader      atomic.Pointer[maxminddb.Reader]
+	lookupCache = cache.New(5*time.Minute, 10*time.Minute)
+	watchOnce   sync.Once
+)
+
+// Load 打开（或重新打开）mmdb 文件，原子替换当前生效的 reader，mmap 方式读取以保持热路径零分配。
+func Load(path string) error {
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+	old := reader.Swap(r)
+	if old != nil {
+		old.Close()
+	}
+	lookupCache.Flush()
+	return nil
+}
+
+// Watch 启动一个后台协程，按 interval 轮询 path 的 mtime，变化时自动热重载。
+func Watch(path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	watchOnce.Do(func() {
+		go func() {
+			var lastMod time.Time
+			if info, err := os.Stat(path); err == nil {
+				lastMod = info.ModTime()
+			}
+			for {
+				time.Sleep(interval)
+				info, err := os.Stat(path)
+				if err != nil || info.ModTime().Equal(lastMod) {
+					continue
+				}
+				if err := Load(path); err != nil {
+					utils.Logger.Warn("geoip 热重载失败", zap.String("path", path), zap.Error(err))
+					continue
+				}
+				lastMod = info.ModTime()
+				utils.Logger.Info("geoip 库已热重载", zap.String("path", path))
+			}
+		}()
+	})
+}
+
+// Lookup 返回 ip 所属国家 ISO 代码与 ASN，单个 ip 的结果会被短期缓存。
+func Lookup(ip net.IP) (string, uint32, error) {
+	key := ip.String()
+	if v, ok := lookupCache.Get(key); ok {
+		r := v.(lookupResult)
+		return r.country, r.asn, nil
+	}
+	r := reader.Load()
+	if r == nil {
+		return "", 0, errNotLoaded
+	}
+	var rec record
+	if err := r.Lookup(ip, &rec); err != nil {
+		return "", 0, err
+	}
+	res := lookupResult{country: rec.Country.ISOCode, asn: rec.AutonomousSystemNumber}
+	lookupCache.Set(key, res, cache.DefaultExpiration)
+	return res.country, res.asn, nil
+}